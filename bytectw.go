@@ -0,0 +1,105 @@
+package ctw
+
+import "math"
+
+// ByteCTW is a Context Tree Weighting based probabilistic model for byte
+// (or more generally N-ary) data. Rather than requiring callers to spray
+// individual bits through 8 separate CTW models, ByteCTW keeps one CTW
+// context tree per node of a depth-8 binary decision tree over the bits of
+// the byte currently being predicted, so bit i is conditioned on both the
+// shared external context and the already-observed higher bits of the same
+// byte. This mirrors the "factored" idea behind FCTW, but the 255 trees
+// share a single external context and the 8 on a byte's path are visited in
+// sequence rather than in parallel, which yields a proper joint P(byte|context) instead of 8
+// independent per-position estimates.
+// ByteCTW implements the arithmetic coding Model interface one bit at a
+// time; ProbByte and ObserveByte offer a byte-oriented API on top of it.
+type ByteCTW struct {
+	Bits  []int       // shared external context preceding the current byte
+	Nodes []*treeNode // context tree roots; index 2*i+bit is node i's child reached by bit
+	node  int         // index into Nodes for the next bit of the current byte
+	seen  []int       // bits of the current byte observed so far, MSB first
+}
+
+// NewByteCTW returns a new ByteCTW whose shared context tree's depth is
+// len(bits). The prior context of the tree is given by bits.
+func NewByteCTW(bits []int) *ByteCTW {
+	nodes := make([]*treeNode, 256)
+	for i := 1; i < 256; i++ {
+		nodes[i] = newTreeNode()
+	}
+	return &ByteCTW{
+		Bits:  bits,
+		Nodes: nodes,
+		node:  1,
+	}
+}
+
+// Prob0 returns the probability that the next bit of the byte currently
+// being predicted be zero.
+func (model *ByteCTW) Prob0() float64 {
+	root := model.Nodes[model.node]
+	before := root.LogProb
+	traversal := update(root, model.Bits, 0, KT)
+	after := root.LogProb
+	revert(traversal)
+	unlock(traversal)
+	return math.Exp(after - before)
+}
+
+// Observe updates the model, given that the next bit of the byte currently
+// being predicted is bit. Once the 8th bit of a byte has been observed, the
+// shared context advances by the whole byte and the model returns to its root.
+func (model *ByteCTW) Observe(bit int) {
+	unlock(update(model.Nodes[model.node], model.Bits, bit, KT))
+	model.seen = append(model.seen, bit)
+	model.node = 2*model.node + bit
+	if len(model.seen) == 8 {
+		for _, b := range model.seen {
+			for i := 1; i < len(model.Bits); i++ {
+				model.Bits[i-1] = model.Bits[i]
+			}
+			model.Bits[len(model.Bits)-1] = b
+		}
+		model.seen = model.seen[:0]
+		model.node = 1
+	}
+}
+
+// ProbByte returns the joint probability of each of the 256 possible values
+// of the byte currently being predicted, conditioned on the shared external
+// context and on any bits already observed for this byte. It does not
+// mutate the model.
+func (model *ByteCTW) ProbByte() [256]float64 {
+	var out [256]float64
+	var walk func(node, value int, depth int, prob float64)
+	walk = func(node, value, depth int, prob float64) {
+		if depth == 8 {
+			out[value] = prob
+			return
+		}
+		root := model.Nodes[node]
+		before := root.LogProb
+		traversal := update(root, model.Bits, 0, KT)
+		p0 := math.Exp(root.LogProb - before)
+		revert(traversal)
+		unlock(traversal)
+		walk(2*node, value<<1, depth+1, prob*p0)
+		walk(2*node+1, value<<1|1, depth+1, prob*(1-p0))
+	}
+	// model.node already encodes the bits seen so far for this byte, MSB
+	// first, under a leading 1 bit (the same encoding Observe builds via
+	// node = 2*node+bit); strip that leading bit to seed value so out's
+	// index is always the full byte, not just the bits still to be decided.
+	depth := len(model.seen)
+	value := model.node - 1<<depth
+	walk(model.node, value, depth, 1.0)
+	return out
+}
+
+// ObserveByte updates the model, given that the stream is followed by byte b.
+func (model *ByteCTW) ObserveByte(b byte) {
+	for i := 7; i >= 0; i-- {
+		model.Observe(int((b >> uint(i)) & 1))
+	}
+}
@@ -0,0 +1,92 @@
+package ctw
+
+import "testing"
+
+// TestByteCTWProbByteSumsToOne checks that ProbByte returns a proper joint
+// distribution over the 256 possible next bytes.
+func TestByteCTWProbByteSumsToOne(t *testing.T) {
+	model := NewByteCTW(make([]int, 8))
+	model.ObserveByte('a')
+	model.ObserveByte('b')
+
+	probs := model.ProbByte()
+	var sum float64
+	for _, p := range probs {
+		sum += p
+	}
+	if diff := sum - 1.0; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("ProbByte sums to %v, want 1", sum)
+	}
+}
+
+// TestByteCTWProbByteMidByte checks that ProbByte works when called partway
+// through a byte (i.e. after 1-7 Observe calls for the byte in progress),
+// rather than only at a byte boundary, and that the bits already committed
+// to constrain which of the 256 outcomes can have nonzero probability.
+func TestByteCTWProbByteMidByte(t *testing.T) {
+	model := NewByteCTW(make([]int, 8))
+	model.Observe(1)
+	model.Observe(0)
+	model.Observe(1)
+
+	probs := model.ProbByte()
+	var sum float64
+	for b, p := range probs {
+		// The byte's top 3 bits are already committed to 1,0,1; any byte
+		// whose top 3 bits differ must have zero probability here.
+		if b>>5 != 0b101 && p != 0 {
+			t.Fatalf("byte %d has top bits %03b, want 101, but probability %v != 0", b, b>>5, p)
+		}
+		sum += p
+	}
+	if diff := sum - 1.0; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("ProbByte sums to %v, want 1", sum)
+	}
+}
+
+// TestByteCTWProbByteDoesNotMutate checks that ProbByte, like Prob0, is a
+// read-only query: calling it must not change what Prob0/ObserveByte see
+// afterward.
+func TestByteCTWProbByteDoesNotMutate(t *testing.T) {
+	model := NewByteCTW(make([]int, 8))
+	model.ObserveByte('x')
+
+	before := model.ProbByte()
+	_ = model.ProbByte()
+	after := model.ProbByte()
+	if before != after {
+		t.Fatalf("ProbByte is not idempotent: got %v then %v", before, after)
+	}
+}
+
+// TestByteCTWProbByteMatchesBitwiseWalk checks that ProbByte's joint
+// probability for a given byte agrees with the probability obtained by
+// walking Prob0/Observe bit by bit on an identically-constructed model, i.e.
+// that the two APIs describe the same distribution.
+func TestByteCTWProbByteMatchesBitwiseWalk(t *testing.T) {
+	model := NewByteCTW(make([]int, 8))
+	model.ObserveByte('a')
+	model.ObserveByte('b')
+	probs := model.ProbByte()
+
+	walker := NewByteCTW(make([]int, 8))
+	walker.ObserveByte('a')
+	walker.ObserveByte('b')
+
+	const want = byte('c')
+	prob := 1.0
+	for i := 7; i >= 0; i-- {
+		bit := int((want >> uint(i)) & 1)
+		p0 := walker.Prob0()
+		if bit == 0 {
+			prob *= p0
+		} else {
+			prob *= 1 - p0
+		}
+		walker.Observe(bit)
+	}
+
+	if diff := probs[want] - prob; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("ProbByte()[%d] = %v, want %v (bitwise walk)", want, probs[want], prob)
+	}
+}
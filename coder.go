@@ -0,0 +1,412 @@
+package ctw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Model is the interface required of a probabilistic source model by the
+// arithmetic coder: it predicts the probability of the next bit being zero,
+// and is then told which bit actually occurred so it can update itself
+// before the following prediction.
+type Model interface {
+	Prob0() float64
+	Observe(bit int)
+}
+
+// The arithmetic coder below is a standard 32-bit integer implementation of
+// Rissanen-Langdon arithmetic coding with underflow (E3) scaling.
+const (
+	codeBits = 32
+	topValue = ^uint32(0)
+	firstQtr = topValue/4 + 1
+	half     = 2 * firstQtr
+	thirdQtr = 3 * firstQtr
+)
+
+// asByteWriter avoids wrapping an already-buffered writer a second time.
+func asByteWriter(w io.Writer) io.ByteWriter {
+	if bw, ok := w.(io.ByteWriter); ok {
+		return bw
+	}
+	return bufio.NewWriter(w)
+}
+
+// asByteReader avoids wrapping an already-buffered reader a second time.
+func asByteReader(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return bufio.NewReader(r)
+}
+
+type bitWriter struct {
+	w   io.ByteWriter
+	cur byte
+	n   uint
+}
+
+func (bw *bitWriter) writeBit(bit int) error {
+	bw.cur = bw.cur<<1 | byte(bit&1)
+	bw.n++
+	if bw.n == 8 {
+		if err := bw.w.WriteByte(bw.cur); err != nil {
+			return err
+		}
+		bw.cur, bw.n = 0, 0
+	}
+	return nil
+}
+
+// flush pads and emits any partial byte, then flushes the underlying writer
+// if it buffers (e.g. a *bufio.Writer asByteWriter fell back to).
+func (bw *bitWriter) flush() error {
+	if bw.n > 0 {
+		bw.cur <<= 8 - bw.n
+		if err := bw.w.WriteByte(bw.cur); err != nil {
+			return err
+		}
+		bw.cur, bw.n = 0, 0
+	}
+	if f, ok := bw.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+type bitReader struct {
+	r   io.ByteReader
+	cur byte
+	n   uint
+}
+
+// readBit returns 0 once the underlying reader is exhausted. The decoder has
+// no way to tell real end-of-stream apart from a still-valid run of zero
+// bits on its own; it is up to the caller's framing (Decompress's length
+// header, or an application protocol for a live Decoder) to stop decoding
+// at the right point.
+func (br *bitReader) readBit() int {
+	if br.n == 0 {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			return 0
+		}
+		br.cur = b
+		br.n = 8
+	}
+	br.n--
+	return int((br.cur >> br.n) & 1)
+}
+
+type arithEncoder struct {
+	bw        *bitWriter
+	low, high uint32
+	pending   int
+}
+
+func newArithEncoder(bw *bitWriter) *arithEncoder {
+	return &arithEncoder{bw: bw, low: 0, high: topValue}
+}
+
+func (e *arithEncoder) outputBitPlusPending(bit int) error {
+	if err := e.bw.writeBit(bit); err != nil {
+		return err
+	}
+	for ; e.pending > 0; e.pending-- {
+		if err := e.bw.writeBit(1 - bit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode narrows [low, high] according to prob0, the probability that bit
+// is zero, then renormalizes, emitting bits and tracking pending E3 bits.
+func (e *arithEncoder) encode(bit int, prob0 float64) error {
+	rng := uint64(e.high) - uint64(e.low) + 1
+	split := e.low + uint32(float64(rng)*prob0) - 1
+	if split < e.low {
+		split = e.low
+	}
+	if split >= e.high {
+		split = e.high - 1
+	}
+	if bit == 0 {
+		e.high = split
+	} else {
+		e.low = split + 1
+	}
+	for {
+		switch {
+		case e.high < half:
+			if err := e.outputBitPlusPending(0); err != nil {
+				return err
+			}
+		case e.low >= half:
+			if err := e.outputBitPlusPending(1); err != nil {
+				return err
+			}
+			e.low -= half
+			e.high -= half
+		case e.low >= firstQtr && e.high < thirdQtr:
+			e.pending++
+			e.low -= firstQtr
+			e.high -= firstQtr
+		default:
+			return nil
+		}
+		e.low *= 2
+		e.high = e.high*2 + 1
+	}
+}
+
+// finish flushes the two bits needed to disambiguate the final interval.
+// Until finish runs, the encoded bits written so far are not a decodable
+// stream: the true value of the source sequence could still fall on either
+// side of a pending renormalization, which is exactly the trailing-bit
+// ambiguity fixed-precision arithmetic coding has to resolve at EOF.
+func (e *arithEncoder) finish() error {
+	e.pending++
+	if e.low < firstQtr {
+		return e.outputBitPlusPending(0)
+	}
+	return e.outputBitPlusPending(1)
+}
+
+type arithDecoder struct {
+	br               *bitReader
+	low, high, value uint32
+}
+
+func newArithDecoder(br *bitReader) *arithDecoder {
+	d := &arithDecoder{br: br, low: 0, high: topValue}
+	for i := 0; i < codeBits; i++ {
+		d.value = d.value<<1 | uint32(br.readBit())
+	}
+	return d
+}
+
+// decode is the mirror of arithEncoder.encode: it narrows [low, high] the
+// same way the encoder did and reports which side of the split value fell on.
+func (d *arithDecoder) decode(prob0 float64) int {
+	rng := uint64(d.high) - uint64(d.low) + 1
+	split := d.low + uint32(float64(rng)*prob0) - 1
+	if split < d.low {
+		split = d.low
+	}
+	if split >= d.high {
+		split = d.high - 1
+	}
+	var bit int
+	if d.value <= split {
+		bit = 0
+		d.high = split
+	} else {
+		bit = 1
+		d.low = split + 1
+	}
+	for {
+		switch {
+		case d.high < half:
+		case d.low >= half:
+			d.low -= half
+			d.high -= half
+			d.value -= half
+		case d.low >= firstQtr && d.high < thirdQtr:
+			d.low -= firstQtr
+			d.high -= firstQtr
+			d.value -= firstQtr
+		default:
+			return bit
+		}
+		d.low *= 2
+		d.high = d.high*2 + 1
+		d.value = d.value*2 + uint32(d.br.readBit())
+	}
+}
+
+// An Encoder arithmetically encodes bytes written to it against a Model,
+// and writes the coded bits to an underlying writer a byte at a time,
+// through a bufio.Writer when that writer is not itself an io.ByteWriter.
+// Call Close once done writing: until then output may not have reached the
+// underlying writer at all, since that buffer, the bit writer's partial
+// byte and the coder's own pending-bit bookkeeping hold back output, and the
+// tie-breaking bits needed to disambiguate the final interval (see
+// arithEncoder.finish) are only known once there is no more input.
+type Encoder struct {
+	model Model
+	enc   *arithEncoder
+	bw    *bitWriter
+}
+
+// NewEncoder returns an Encoder that arithmetically codes bytes written to
+// it against model, writing the coded output to w.
+func NewEncoder(w io.Writer, model Model) *Encoder {
+	bw := &bitWriter{w: asByteWriter(w)}
+	return &Encoder{model: model, enc: newArithEncoder(bw), bw: bw}
+}
+
+// Write encodes each byte of p in turn, predicting from and updating model
+// as it goes, most significant bit first - the order ByteCTW's "byte" mode
+// conditions each bit on the higher bits already observed for the same
+// byte (see bytectw.go), and the order ObserveByte drives it in directly.
+// It either encodes all of p or returns the error that stopped it partway
+// through.
+func (e *Encoder) Write(p []byte) (int, error) {
+	for i, b := range p {
+		for j := 7; j >= 0; j-- {
+			bit := int((b >> uint(j)) & 1)
+			if err := e.enc.encode(bit, e.model.Prob0()); err != nil {
+				return i, fmt.Errorf("ctw: encoding: %w", err)
+			}
+			e.model.Observe(bit)
+		}
+	}
+	return len(p), nil
+}
+
+// Close flushes the arithmetic coder's pending state and the bit writer's
+// partial byte, and flushes the underlying writer if it buffers. No more
+// data should be written to the Encoder afterward.
+func (e *Encoder) Close() error {
+	if err := e.enc.finish(); err != nil {
+		return fmt.Errorf("ctw: flushing encoder: %w", err)
+	}
+	return e.bw.flush()
+}
+
+// A Decoder arithmetically decodes bytes read from it against a Model, the
+// same way the Encoder that produced them predicted and updated it.
+type Decoder struct {
+	model Model
+	dec   *arithDecoder
+}
+
+// NewDecoder returns a Decoder that reads arithmetically coded bytes from r
+// and decodes them against model. Constructing it primes the coder by
+// reading codeBits bits from r immediately.
+func NewDecoder(r io.Reader, model Model) *Decoder {
+	br := &bitReader{r: asByteReader(r)}
+	return &Decoder{model: model, dec: newArithDecoder(br)}
+}
+
+// Read decodes len(p) bytes into p, predicting from and updating model as it
+// goes, most significant bit first (see Write), and always fills p
+// completely. The coder has no way to detect the end of its own stream -
+// only the caller's framing (a known length, an application-level
+// terminator, ...) can do that, so Read keeps decoding (effectively from a
+// run of zero bits once the source is exhausted) rather than returning
+// io.EOF on its own.
+func (d *Decoder) Read(p []byte) (int, error) {
+	for i := range p {
+		var b byte
+		for j := 7; j >= 0; j-- {
+			bit := d.dec.decode(d.model.Prob0())
+			d.model.Observe(bit)
+			b |= byte(bit) << uint(j)
+		}
+		p[i] = b
+	}
+	return len(p), nil
+}
+
+// newModel constructs the Model selected by mode, ready to predict from an
+// empty context of the given depth. The empty string selects the original
+// per-bit CTW; "byte" selects ByteCTW (see bytectw.go).
+func newModel(mode string, depth int) (Model, error) {
+	switch mode {
+	case "", "bit":
+		return NewCTW(make([]int, depth)), nil
+	case "byte":
+		return NewByteCTW(make([]int, depth)), nil
+	default:
+		return nil, fmt.Errorf("ctw: unknown mode %q", mode)
+	}
+}
+
+// Compress reads the file named filename and writes an arithmetically coded
+// version of it to w, predicted by a freshly initialized model of the given
+// context depth and mode ("bit" or "byte"; "" selects "bit"). It writes the
+// source size as a header in front of the coded body so Decompress knows
+// when to stop, then streams the body through an Encoder.
+func Compress(w io.Writer, filename string, depth int, mode string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("ctw: opening %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("ctw: stating %s: %w", filename, err)
+	}
+
+	model, err := newModel(mode, depth)
+	if err != nil {
+		return err
+	}
+
+	header := bufio.NewWriter(w)
+	if err := binary.Write(header, binary.LittleEndian, uint64(info.Size())); err != nil {
+		return fmt.Errorf("ctw: writing header: %w", err)
+	}
+	if err := header.Flush(); err != nil {
+		return fmt.Errorf("ctw: writing header: %w", err)
+	}
+
+	enc := NewEncoder(w, model)
+	r := bufio.NewReader(f)
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := enc.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("ctw: reading %s: %w", filename, err)
+		}
+	}
+	return enc.Close()
+}
+
+// Decompress reads an arithmetically coded stream from r, produced by
+// Compress with the same depth and mode, and writes the decoded bytes to w,
+// streaming the body through a Decoder.
+func Decompress(w io.Writer, r io.Reader, depth int, mode string) error {
+	header := bufio.NewReader(r)
+	var size uint64
+	if err := binary.Read(header, binary.LittleEndian, &size); err != nil {
+		return fmt.Errorf("ctw: reading header: %w", err)
+	}
+
+	model, err := newModel(mode, depth)
+	if err != nil {
+		return err
+	}
+
+	dec := NewDecoder(header, model)
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 4096)
+	for remaining := size; remaining > 0; {
+		n := uint64(len(buf))
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := io.ReadFull(dec, buf[:n]); err != nil {
+			return fmt.Errorf("ctw: decoding: %w", err)
+		}
+		if _, err := bw.Write(buf[:n]); err != nil {
+			return fmt.Errorf("ctw: writing output: %w", err)
+		}
+		remaining -= n
+	}
+	return bw.Flush()
+}
@@ -10,11 +10,13 @@ import (
 )
 
 var depth = flag.Int("depth", 56, "depth of Context Tree Weighting")
+var mode = flag.String("mode", "bit", "prediction mode: bit or byte")
 var verbose = flag.Bool("verbose", false, "verbosity")
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s [flags] sroucefilename targetfilename\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] sourcefilename [targetfilename]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "targetfilename defaults to \"-\" (stdout). sourcefilename must name a\nreal file: Compress needs its size up front for the stream header, so it\ncan't read from a pipe.\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
@@ -24,21 +26,21 @@ func main() {
 		os.Exit(1)
 	}
 
-	// a second path for compressed results
+	// a second path for compressed results; "-" or omitted means stdout, for
+	// piping into another tool.
 	name2 := flag.Arg(1)
-	if name2 == "" {
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	f, err := os.Create(name2)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when reading input files\n")
-		return
+	w := os.Stdout
+	if name2 != "" && name2 != "-" {
+		f, err := os.Create(name2)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error when creating %s\n", name2)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
 	}
-	defer f.Close()
 
-	if err := ctw.Compress(f, name, *depth); err != nil {
+	if err := ctw.Compress(w, name, *depth, *mode); err != nil {
 		log.Fatalf("%v", err)
 	}
 }
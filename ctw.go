@@ -14,6 +14,7 @@ package ctw
 import (
 	"log"
 	"math"
+	"sync"
 )
 
 // logaddexp performs log(exp(x) + exp(y))
@@ -41,6 +42,20 @@ type treeNode struct {
 
 	Left  *treeNode // the sub-suffix that ends with one
 	Right *treeNode // the sub-suffix that ends with zero
+
+	// mu guards A, B, Lktp, LogProb, Left, and Right so that concurrent
+	// access to the same tree from multiple goroutines is race-free (see
+	// update's doc comment for why this serializes rather than
+	// parallelizes). It is a pointer so that copying a treeNode (see
+	// snapshot below) never copies a lock, and is left nil on values decoded
+	// by Load/LoadJSON; newTreeNode is the only constructor that produces a
+	// node ready to be locked.
+	mu *sync.Mutex
+}
+
+// newTreeNode returns a treeNode ready for concurrent use.
+func newTreeNode() *treeNode {
+	return &treeNode{mu: &sync.Mutex{}}
 }
 
 type snapshot struct {
@@ -49,6 +64,9 @@ type snapshot struct {
 	isNew bool
 }
 
+// revert undoes the mutations recorded by update, given the same traversal
+// it returned. The caller must still hold the locks update acquired; use
+// unlock to release them once revert has returned.
 func revert(traversed []snapshot) {
 	for i, ss := range traversed {
 		node := ss.node
@@ -76,11 +94,41 @@ func revert(traversed []snapshot) {
 	}
 }
 
+// unlock releases the per-node locks update acquired along a traversal, once
+// the caller (Observe, or Prob0 after calling revert) is done with them.
+func unlock(traversed []snapshot) {
+	for i := len(traversed) - 1; i >= 0; i-- {
+		traversed[i].node.mu.Unlock()
+	}
+}
+
 // update updates the tree according to the rules of CTW.
 // Root is the root of the context tree.
 // Bits is the last few bits of the sequence, len(bits) should be the depth of the tree.
 // Bit is the new bit following the sequence.
-func update(root *treeNode, bits []int, bit int) []snapshot {
+//
+// update locks each node it visits, in root-to-leaf order, and leaves them
+// locked on return so that a caller computing a speculative Prob0 can revert
+// before anyone else observes the intermediate state; every caller must
+// release those locks with unlock once it is done. Because every traversal
+// starts at the same root and proceeds strictly downward, two concurrent
+// traversals can never wait on each other's locks in a cycle.
+//
+// This makes concurrent access to one CTW race-free, not parallel: since
+// every traversal locks the root first and holds every node it visits until
+// the caller's unlock, a second call on the same tree blocks at the root
+// until the first call is fully done, however much their paths diverge
+// after that. Real disjoint-path throughput would need each ancestor's lock
+// released as soon as its child is settled (hand-over-hand), but revert
+// above needs every traversed node's pre-update state still protected until
+// it runs, and the backprop loop below reads a sibling's LogProb without
+// locking it (safe only because holding the root lock for the whole call
+// already serializes every other traversal) - so that's future work, not
+// what this implementation does.
+//
+// estimator selects the per-node probability law (see estimator.go); pass
+// KT for the original Krichevsky-Trofimov behavior.
+func update(root *treeNode, bits []int, bit int, estimator Estimator) []snapshot {
 	if bit != 0 && bit != 1 {
 		log.Fatalf("wrong bit %d", bit)
 	}
@@ -88,27 +136,31 @@ func update(root *treeNode, bits []int, bit int) []snapshot {
 	// Update the counts of zeros and ones of each node.
 	traversed := []snapshot{}
 	node := root
+	node.mu.Lock()
 	traversed = append(traversed, snapshot{node: node, state: *node, isNew: false})
-	krichevskyTrofimov(node, bit)
+	updateEstimate(node, estimator, bit)
 
 	for d := 0; d < len(bits); d++ {
 		isNew := false
+		var next *treeNode
 		if bits[len(bits)-1-d] == 0 {
 			if node.Right == nil {
-				node.Right = &treeNode{}
+				node.Right = newTreeNode()
 				isNew = true
 			}
-			node = node.Right
+			next = node.Right
 		} else {
 			if node.Left == nil {
-				node.Left = &treeNode{}
+				node.Left = newTreeNode()
 				isNew = true
 			}
-			node = node.Left
+			next = node.Left
 		}
+		next.mu.Lock()
+		node = next
 
 		traversed = append(traversed, snapshot{node: node, state: *node, isNew: isNew})
-		krichevskyTrofimov(node, bit)
+		updateEstimate(node, estimator, bit)
 	}
 
 	// Update the actual node probabilities.
@@ -135,17 +187,11 @@ func update(root *treeNode, bits []int, bit int) []snapshot {
 	return traversed
 }
 
-// krichevskyTrofimov updates the Krichevsky-Trofimov estimate of a node given a new observed bit.
+// krichevskyTrofimov updates a node via the default KT estimator. It is a
+// convenience alias for updateEstimate(node, KT, bit), for callers
+// (TrackedCTW) that don't need a pluggable Estimator; see estimator.go.
 func krichevskyTrofimov(node *treeNode, bit int) {
-	a := float64(node.A)
-	b := float64(node.B)
-	if bit == 0 {
-		node.Lktp = node.Lktp + math.Log(a+0.5) - math.Log(a+b+1)
-		node.A += 1
-	} else {
-		node.Lktp = node.Lktp + math.Log(b+0.5) - math.Log(a+b+1)
-		node.B += 1
-	}
+	updateEstimate(node, KT, bit)
 }
 
 // A CTW is a Context Tree Weighting based probabilistic model for binary data.
@@ -153,36 +199,60 @@ func krichevskyTrofimov(node *treeNode, bit int) {
 type CTW struct {
 	Bits []int
 	Root *treeNode
+
+	estimator Estimator
 }
 
-// NewCTW returns a new CTW whose context tree's depth is len(bits).
-// The prior context of the tree is given by bits.
+// NewCTW returns a new CTW whose context tree's depth is len(bits), using
+// the default Krichevsky-Trofimov estimator. The prior context of the tree
+// is given by bits.
 func NewCTW(bits []int) *CTW {
-	model := &CTW{
-		Bits: bits,
-		Root: &treeNode{},
+	return NewCTWWithEstimator(bits, KT)
+}
+
+// NewCTWWithEstimator is like NewCTW, but uses estimator (see estimator.go)
+// in place of the default Krichevsky-Trofimov estimator at every node.
+func NewCTWWithEstimator(bits []int, estimator Estimator) *CTW {
+	return &CTW{
+		Bits:      bits,
+		Root:      newTreeNode(),
+		estimator: estimator,
 	}
-	return model
+}
+
+// initLocks recursively ensures every node in the subtree rooted at node has
+// a lock. Load and LoadJSON reconstruct trees whose mu fields are not part
+// of the serialized format, so they call this before returning the model.
+func (node *treeNode) initLocks() {
+	if node == nil {
+		return
+	}
+	if node.mu == nil {
+		node.mu = &sync.Mutex{}
+	}
+	node.Left.initLocks()
+	node.Right.initLocks()
 }
 
 // Prob0 returns the probability that the next bit be zero.
 func (model *CTW) Prob0() float64 {
 	before := model.Root.LogProb
-	traversal := update(model.Root, model.Bits, 0)
+	traversal := update(model.Root, model.Bits, 0, model.estimator)
 	after := model.Root.LogProb
 
 	revert(traversal)
+	unlock(traversal)
 
 	return math.Exp(after - before)
 }
 
 // Observe updates the context tree, given that the sequence is followed by bit.
 func (model *CTW) Observe(bit int) {
-	model.observe(bit)
+	unlock(model.observe(bit))
 }
 
 func (model *CTW) observe(bit int) []snapshot {
-	traversal := update(model.Root, model.Bits, bit)
+	traversal := update(model.Root, model.Bits, bit, model.estimator)
 	for i := 1; i < len(model.Bits); i++ {
 		model.Bits[i-1] = model.Bits[i]
 	}
@@ -192,6 +262,8 @@ func (model *CTW) observe(bit int) []snapshot {
 
 // A CTWReverter is a CTW model that allows reverting to its previous state.
 // This is useful for predicting several steps ahead, while keeping the model's original state intact.
+// While an observation is pending Unobserve, the nodes it touched stay
+// locked against other goroutines, the same way a speculative Prob0 does.
 type CTWReverter struct {
 	model      *CTW
 	bits       []int
@@ -217,6 +289,7 @@ func (cr *CTWReverter) Unobserve() {
 	// Revert the tree.
 	tvIdx := len(cr.traversals) - 1
 	revert(cr.traversals[tvIdx])
+	unlock(cr.traversals[tvIdx])
 	cr.traversals = cr.traversals[:tvIdx]
 
 	// Revert the context bits.
@@ -237,13 +310,26 @@ type FCTW struct {
 	Index     int
 }
 
-// NewFCTW returns a new FCTW whose context tree's depth is len(bits).
-// The prior context of the trees is given by bits.
-// The initial index position is len(bits) mod block_len.
+// NewFCTW returns a new FCTW whose context tree's depth is len(bits), using
+// the default Krichevsky-Trofimov estimator. The prior context of the trees
+// is given by bits. The initial index position is len(bits) mod block_len.
 func NewFCTW(block_len int, bits []int) *FCTW {
+	return NewFCTWWithEstimator(block_len, bits, KT)
+}
+
+// NewFCTWWithEstimator is like NewFCTW, but uses estimator (see
+// estimator.go) in place of the default Krichevsky-Trofimov estimator in
+// every sub-tree.
+func NewFCTWWithEstimator(block_len int, bits []int, estimator Estimator) *FCTW {
 	trees := make([]*CTW, block_len)
 	for i := 0; i < block_len; i++ {
-		trees[i] = NewCTW(bits)
+		// Each sub-tree owns an independent copy of the context, rather than
+		// sharing one backing array, because Observe feeds every bit to every
+		// sub-tree and each one's observe shifts its own Bits; with a shared
+		// array the context would shift Block_len times per bit.
+		treeBits := make([]int, len(bits))
+		copy(treeBits, bits)
+		trees[i] = NewCTWWithEstimator(treeBits, estimator)
 	}
 	index := len(bits) % block_len
 	model := &FCTW{
@@ -258,18 +344,29 @@ func NewFCTW(block_len int, bits []int) *FCTW {
 func (model *FCTW) Prob0() float64 {
 	tree := model.Trees[model.Index]
 	before := tree.Root.LogProb
-	traversal := update(tree.Root, tree.Bits, 0)
+	traversal := update(tree.Root, tree.Bits, 0, tree.estimator)
 	after := tree.Root.LogProb
 
 	revert(traversal)
+	unlock(traversal)
 
 	return math.Exp(after - before)
 }
 
 // Observe updates the context tree, given that the sequence is followed by bit.
+// Each of the Block_len sub-trees owns an independent context path, so in
+// principle they could be updated concurrently; in practice a single
+// Observe does tree-depth-many node updates per sub-tree (a handful of
+// pointer writes and float additions each), which is too little work to
+// amortize the cost of spawning a goroutine per sub-tree per bit.
+// BenchmarkFCTWObserve measures Observe at several block lengths; spawning
+// goroutines here made every block length it covers slower, not faster, so
+// Observe stays sequential. The per-node mutexes update takes still matter - they guard
+// concurrent access from callers like CTWReverter and TrackedCTW - they just
+// aren't worth contending on within a single FCTW.Observe call.
 func (model *FCTW) Observe(bit int) {
 	for i := 0; i < model.Block_len; i++ {
-		model.Trees[i].observe(bit)
+		unlock(model.Trees[i].observe(bit))
 	}
 	model.Index = (model.Index + 1) % model.Block_len
 }
@@ -311,16 +408,18 @@ func ToVOM(model *CTW) *VOM {
 	bits := make([]int, len(model.Bits))
 	_ = copy(bits, model.Bits)
 	vom_model := &VOM{
-		ToVOMNode(model.Root),
+		ToVOMNode(model.Root, model.estimator),
 		bits,
 	}
 	return vom_model
 }
 
-func ToVOMNode(node *treeNode) *VOMNode {
-	a := float64(node.A)
-	b := float64(node.B)
-	ktp := (a + 0.5) / (a + b + 1.0)
+// ToVOMNode recursively converts a context tree node into a VOMNode,
+// computing each leaf's conditional probability from estimator rather than
+// assuming Krichevsky-Trofimov, so a VOM derived from a CTW built with
+// NewCTWWithEstimator reflects that choice.
+func ToVOMNode(node *treeNode, estimator Estimator) *VOMNode {
+	ktp := math.Exp(estimatorLogProb0(estimator, node.A, node.B, 0))
 	if node.Left == nil && node.Right == nil {
 		// fmt.Print("Reached terminal node")
 		return &VOMNode{
@@ -334,13 +433,13 @@ func ToVOMNode(node *treeNode) *VOMNode {
 	var LeftVOM *VOMNode = nil
 	var mlp float64 = 0.0
 	if node.Left != nil {
-		LeftVOM = ToVOMNode(node.Left)
+		LeftVOM = ToVOMNode(node.Left, estimator)
 		mlp = LeftVOM.MaxLogProb
 	}
 	var RightVOM *VOMNode = nil
 	var mrp float64 = 0.0
 	if node.Right != nil {
-		RightVOM = ToVOMNode(node.Right)
+		RightVOM = ToVOMNode(node.Right, estimator)
 		mrp = RightVOM.MaxLogProb
 	}
 	if node.Lktp >= mlp+mrp {
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -10,43 +11,42 @@ import (
 )
 
 var depth = flag.Int("depth", 56, "depth of Context Tree Weighting")
+var mode = flag.String("mode", "bit", "prediction mode: bit or byte")
 
 func main() {
-	// like compressor, take in a file name
+	// Both paths are optional: "-" or omitted means stdin/stdout, so this
+	// can sit in a pipeline, e.g. `cat file.ctw | decompress | player`.
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s [flags] sroucefilename targetfilename\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] [sourcefilename] [targetfilename]\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 	flag.Parse()
+
 	name := flag.Arg(0)
-	if name == "" {
-		flag.Usage()
-		os.Exit(1)
+	r := io.Reader(os.Stdin)
+	if name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error when opening %s\n", name)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
 	}
 
 	name2 := flag.Arg(1)
-	if name2 == "" {
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	// read the file to an io.reader
-	f1, err := os.Open(name)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when reading input files\n")
-		return
-	}
-	defer f1.Close()
-
-	// read the file to an io.reader
-	f2, err := os.Create(name2)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error when reading input files\n")
-		return
+	w := io.Writer(os.Stdout)
+	if name2 != "" && name2 != "-" {
+		f, err := os.Create(name2)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error when creating %s\n", name2)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
 	}
-	defer f2.Close()
 
-	if err := ctw.Decompress(f2, f1, *depth); err != nil {
+	if err := ctw.Decompress(w, r, *depth, *mode); err != nil {
 		log.Fatalf("%v", err)
 	}
 }
@@ -0,0 +1,26 @@
+package ctw
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// BenchmarkFCTWObserve drives FCTW.Observe the way train_model drives a CTW
+// over a WAV file, at a range of block lengths. Observe updates its
+// Block_len sub-trees sequentially (see the doc comment on Observe): an
+// earlier version of this benchmark justified spawning one goroutine per
+// sub-tree per bit, but at these block lengths the per-update work is too
+// small to amortize goroutine spawn cost.
+func BenchmarkFCTWObserve(b *testing.B) {
+	for _, blockLen := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("block_len=%d", blockLen), func(b *testing.B) {
+			model := NewFCTW(blockLen, make([]int, 16))
+			rng := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				model.Observe(rng.Intn(2))
+			}
+		})
+	}
+}
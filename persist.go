@@ -0,0 +1,252 @@
+package ctw
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// formatVersion is bumped whenever the binary encoding written by Save
+// changes in a way that isn't backward compatible with Load.
+const formatVersion = 1
+
+// header precedes the gob-encoded model body written by Save. Reading it
+// first lets Load reject an unsupported format version or a different model
+// kind before committing to decoding a potentially very large body. Depth
+// and BlockLen are recorded but not checked on load.
+type header struct {
+	Version   int
+	Kind      string // "ctw", "fctw", or "vom"
+	Depth     int    // length of the prior context in bits
+	BlockLen  int    // FCTW only; 0 otherwise
+	Estimator string // "kt" or "zr"; CTW/FCTW only, empty selects KT
+}
+
+// writeHeader and readHeader share the caller's gob.Encoder/Decoder so the
+// header and body are two values on the same streaming gob connection,
+// rather than two independently framed documents.
+func writeHeader(enc *gob.Encoder, h header) error {
+	if err := enc.Encode(h); err != nil {
+		return fmt.Errorf("ctw: writing header: %w", err)
+	}
+	return nil
+}
+
+func readHeader(dec *gob.Decoder, wantKind string) (header, error) {
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return h, fmt.Errorf("ctw: reading header: %w", err)
+	}
+	if h.Version != formatVersion {
+		return h, fmt.Errorf("ctw: unsupported format version %d", h.Version)
+	}
+	if h.Kind != wantKind {
+		return h, fmt.Errorf("ctw: expected a %s model, found %q", wantKind, h.Kind)
+	}
+	return h, nil
+}
+
+// Save writes model in a compact binary (gob) encoding to w. gob encodes the
+// whole model as a single message, so the encoded form is held in memory in
+// full before it is written. Load reconstructs an equivalent model from that
+// encoding.
+func (model *CTW) Save(w io.Writer) error {
+	name, err := estimatorName(model.estimator)
+	if err != nil {
+		return err
+	}
+	enc := gob.NewEncoder(w)
+	h := header{Version: formatVersion, Kind: "ctw", Depth: len(model.Bits), Estimator: name}
+	if err := writeHeader(enc, h); err != nil {
+		return err
+	}
+	if err := enc.Encode(model); err != nil {
+		return fmt.Errorf("ctw: writing model: %w", err)
+	}
+	return nil
+}
+
+// Load reads a CTW previously written by Save.
+func Load(r io.Reader) (*CTW, error) {
+	dec := gob.NewDecoder(r)
+	h, err := readHeader(dec, "ctw")
+	if err != nil {
+		return nil, err
+	}
+	estimator, err := estimatorByName(h.Estimator)
+	if err != nil {
+		return nil, err
+	}
+	model := &CTW{}
+	if err := dec.Decode(model); err != nil {
+		return nil, fmt.Errorf("ctw: reading model: %w", err)
+	}
+	model.Root.initLocks()
+	model.estimator = estimator
+	return model, nil
+}
+
+// SaveJSON writes model as human-readable JSON to w, preceded by a header
+// line so LoadJSON can recover model.estimator the same way Load does.
+func (model *CTW) SaveJSON(w io.Writer) error {
+	name, err := estimatorName(model.estimator)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	h := header{Version: formatVersion, Kind: "ctw", Depth: len(model.Bits), Estimator: name}
+	if err := enc.Encode(h); err != nil {
+		return fmt.Errorf("ctw: writing header: %w", err)
+	}
+	return enc.Encode(model)
+}
+
+// LoadJSON reads a CTW previously written by SaveJSON.
+func LoadJSON(r io.Reader) (*CTW, error) {
+	dec := json.NewDecoder(r)
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return nil, fmt.Errorf("ctw: reading header: %w", err)
+	}
+	estimator, err := estimatorByName(h.Estimator)
+	if err != nil {
+		return nil, err
+	}
+	model := &CTW{}
+	if err := dec.Decode(model); err != nil {
+		return nil, fmt.Errorf("ctw: reading model: %w", err)
+	}
+	model.Root.initLocks()
+	model.estimator = estimator
+	return model, nil
+}
+
+// Save writes model in a compact binary encoding to w. Load reconstructs an
+// equivalent model from that encoding.
+func (model *FCTW) Save(w io.Writer) error {
+	depth := 0
+	var name string
+	if len(model.Trees) > 0 {
+		depth = len(model.Trees[0].Bits)
+		var err error
+		if name, err = estimatorName(model.Trees[0].estimator); err != nil {
+			return err
+		}
+	}
+	enc := gob.NewEncoder(w)
+	h := header{Version: formatVersion, Kind: "fctw", Depth: depth, BlockLen: model.Block_len, Estimator: name}
+	if err := writeHeader(enc, h); err != nil {
+		return err
+	}
+	if err := enc.Encode(model); err != nil {
+		return fmt.Errorf("ctw: writing model: %w", err)
+	}
+	return nil
+}
+
+// LoadFCTW reads an FCTW previously written by FCTW.Save.
+func LoadFCTW(r io.Reader) (*FCTW, error) {
+	dec := gob.NewDecoder(r)
+	h, err := readHeader(dec, "fctw")
+	if err != nil {
+		return nil, err
+	}
+	estimator, err := estimatorByName(h.Estimator)
+	if err != nil {
+		return nil, err
+	}
+	model := &FCTW{}
+	if err := dec.Decode(model); err != nil {
+		return nil, fmt.Errorf("ctw: reading model: %w", err)
+	}
+	for _, tree := range model.Trees {
+		tree.Root.initLocks()
+		tree.estimator = estimator
+	}
+	return model, nil
+}
+
+// SaveJSON writes model as human-readable JSON to w, preceded by a header
+// line so LoadFCTWJSON can recover each sub-tree's estimator the same way
+// LoadFCTW does.
+func (model *FCTW) SaveJSON(w io.Writer) error {
+	var name string
+	if len(model.Trees) > 0 {
+		var err error
+		if name, err = estimatorName(model.Trees[0].estimator); err != nil {
+			return err
+		}
+	}
+	enc := json.NewEncoder(w)
+	h := header{Version: formatVersion, Kind: "fctw", BlockLen: model.Block_len, Estimator: name}
+	if err := enc.Encode(h); err != nil {
+		return fmt.Errorf("ctw: writing header: %w", err)
+	}
+	return enc.Encode(model)
+}
+
+// LoadFCTWJSON reads an FCTW previously written by FCTW.SaveJSON.
+func LoadFCTWJSON(r io.Reader) (*FCTW, error) {
+	dec := json.NewDecoder(r)
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return nil, fmt.Errorf("ctw: reading header: %w", err)
+	}
+	estimator, err := estimatorByName(h.Estimator)
+	if err != nil {
+		return nil, err
+	}
+	model := &FCTW{}
+	if err := dec.Decode(model); err != nil {
+		return nil, fmt.Errorf("ctw: reading model: %w", err)
+	}
+	for _, tree := range model.Trees {
+		tree.Root.initLocks()
+		tree.estimator = estimator
+	}
+	return model, nil
+}
+
+// Save writes model in a compact binary encoding to w. Load reconstructs an
+// equivalent model from that encoding. This replaces hand-rolling
+// json.Marshal over a VOM produced by ToVOM.
+func (model *VOM) Save(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	h := header{Version: formatVersion, Kind: "vom", Depth: len(model.Bits)}
+	if err := writeHeader(enc, h); err != nil {
+		return err
+	}
+	if err := enc.Encode(model); err != nil {
+		return fmt.Errorf("ctw: writing model: %w", err)
+	}
+	return nil
+}
+
+// LoadVOM reads a VOM previously written by VOM.Save.
+func LoadVOM(r io.Reader) (*VOM, error) {
+	dec := gob.NewDecoder(r)
+	if _, err := readHeader(dec, "vom"); err != nil {
+		return nil, err
+	}
+	model := &VOM{}
+	if err := dec.Decode(model); err != nil {
+		return nil, fmt.Errorf("ctw: reading model: %w", err)
+	}
+	return model, nil
+}
+
+// SaveJSON writes model as human-readable JSON to w, the format previously
+// produced ad hoc by the trainer via json.Marshal.
+func (model *VOM) SaveJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(model)
+}
+
+// LoadVOMJSON reads a VOM previously written by VOM.SaveJSON.
+func LoadVOMJSON(r io.Reader) (*VOM, error) {
+	model := &VOM{}
+	if err := json.NewDecoder(r).Decode(model); err != nil {
+		return nil, fmt.Errorf("ctw: reading model: %w", err)
+	}
+	return model, nil
+}
@@ -0,0 +1,112 @@
+package ctw
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+// observeSequence feeds bits into model in order.
+func observeSequence(model Model, bits []int) {
+	for _, b := range bits {
+		model.Observe(b)
+	}
+}
+
+// TestCTWSaveLoadPreservesEstimator checks that a CTW built with a
+// non-default Estimator (ZR) keeps predicting the same way after a
+// binary Save/Load round trip, rather than silently reverting to KT.
+func TestCTWSaveLoadPreservesEstimator(t *testing.T) {
+	model := NewCTWWithEstimator(make([]int, 4), ZR)
+	observeSequence(model, []int{0, 0, 0, 1, 0, 0, 1, 1})
+	want := model.Prob0()
+
+	var buf bytes.Buffer
+	if err := model.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loaded.Prob0(); got != want {
+		t.Fatalf("Prob0 after Load = %v, want %v (estimator not preserved)", got, want)
+	}
+}
+
+// TestCTWSaveLoadJSONPreservesEstimator is the JSON-format analogue of
+// TestCTWSaveLoadPreservesEstimator.
+func TestCTWSaveLoadJSONPreservesEstimator(t *testing.T) {
+	model := NewCTWWithEstimator(make([]int, 4), ZR)
+	observeSequence(model, []int{0, 0, 0, 1, 0, 0, 1, 1})
+	want := model.Prob0()
+
+	var buf bytes.Buffer
+	if err := model.SaveJSON(&buf); err != nil {
+		t.Fatalf("SaveJSON: %v", err)
+	}
+	loaded, err := LoadJSON(&buf)
+	if err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	if got := loaded.Prob0(); got != want {
+		t.Fatalf("Prob0 after LoadJSON = %v, want %v (estimator not preserved)", got, want)
+	}
+}
+
+// TestFCTWSaveLoadPreservesEstimator is the FCTW analogue, covering both
+// binary and JSON encodings.
+func TestFCTWSaveLoadPreservesEstimator(t *testing.T) {
+	model := NewFCTWWithEstimator(2, make([]int, 4), ZR)
+	observeSequence(model, []int{0, 0, 0, 1, 0, 0, 1, 1})
+	want := model.Prob0()
+
+	var buf bytes.Buffer
+	if err := model.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadFCTW(&buf)
+	if err != nil {
+		t.Fatalf("LoadFCTW: %v", err)
+	}
+	if got := loaded.Prob0(); got != want {
+		t.Fatalf("Prob0 after LoadFCTW = %v, want %v (estimator not preserved)", got, want)
+	}
+
+	var jsonBuf bytes.Buffer
+	if err := model.SaveJSON(&jsonBuf); err != nil {
+		t.Fatalf("SaveJSON: %v", err)
+	}
+	jsonLoaded, err := LoadFCTWJSON(&jsonBuf)
+	if err != nil {
+		t.Fatalf("LoadFCTWJSON: %v", err)
+	}
+	if got := jsonLoaded.Prob0(); got != want {
+		t.Fatalf("Prob0 after LoadFCTWJSON = %v, want %v (estimator not preserved)", got, want)
+	}
+}
+
+// TestLoadDefaultsToKTForOlderHeader checks that a header written without an
+// Estimator field (as every file written before this field existed was)
+// still loads as a KT model.
+func TestLoadDefaultsToKTForOlderHeader(t *testing.T) {
+	model := NewCTW(make([]int, 4))
+	observeSequence(model, []int{0, 0, 0, 1, 0, 0, 1, 1})
+	want := model.Prob0()
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := writeHeader(enc, header{Version: formatVersion, Kind: "ctw", Depth: len(model.Bits)}); err != nil {
+		t.Fatalf("writeHeader: %v", err)
+	}
+	if err := enc.Encode(model); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loaded.Prob0(); got != want {
+		t.Fatalf("Prob0 after Load = %v, want %v", got, want)
+	}
+}
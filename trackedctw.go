@@ -0,0 +1,204 @@
+package ctw
+
+import "math"
+
+// TrackedCTW wraps a CTW-style context tree, but only grows full per-level
+// nodes along caller-declared context paths. Every context outside that set
+// collapses onto a single shared KT-only leaf instead of growing its own
+// nodes, which bounds memory on long inputs at large tree depths (update
+// otherwise allocates up to len(Bits) new nodes for every previously-unseen
+// context). ForgetPast lets a caller reclaim tracked paths that have gone
+// cold, rather than tracking them forever.
+// TrackedCTW implements the arithmetic coding Model interface.
+type TrackedCTW struct {
+	Bits []int
+	Root *treeNode
+
+	shared *treeNode // the KT-only leaf every untracked path collapses onto
+
+	tracked map[string]struct{} // tracked prefixes, keyed by traversal order
+	lastObs map[string]int      // observation index a tracked prefix was last touched at
+	obs     int                 // number of observations so far
+}
+
+// NewTrackedCTW returns a new TrackedCTW whose context tree's depth is
+// len(bits) and prior context is given by bits. No prefixes are tracked
+// until Track is called, so it starts out fully collapsed onto the shared
+// leaf.
+func NewTrackedCTW(bits []int) *TrackedCTW {
+	return &TrackedCTW{
+		Bits:    bits,
+		Root:    newTreeNode(),
+		shared:  newTreeNode(),
+		tracked: map[string]struct{}{},
+		lastObs: map[string]int{},
+	}
+}
+
+// prefixKey renders a traversal-order bit path as a map key.
+func prefixKey(path []int) string {
+	buf := make([]byte, len(path))
+	for i, b := range path {
+		if b == 0 {
+			buf[i] = '0'
+		} else {
+			buf[i] = '1'
+		}
+	}
+	return string(buf)
+}
+
+// Track declares that the context path given by prefixBits should get its
+// own tree nodes instead of collapsing into the shared untracked leaf.
+// prefixBits is given in traversal order: prefixBits[0] is consulted first
+// (the most recent bit of the context), prefixBits[1] next, and so on.
+// Every ancestor of prefixBits is tracked implicitly, since a node can't be
+// realized without its parent being realized too.
+func (model *TrackedCTW) Track(prefixBits []int) {
+	for l := 1; l <= len(prefixBits); l++ {
+		model.tracked[prefixKey(prefixBits[:l])] = struct{}{}
+	}
+}
+
+// traverse mirrors update, except that once the path being walked leaves the
+// tracked set it collapses onto the shared leaf rather than allocating a new
+// node, and stops descending any further. It returns the traversed nodes,
+// still locked (see update), for the caller to revert or unlock.
+//
+// mark controls whether tracked prefixes along the path are stamped as
+// freshly touched in lastObs. Prob0's traversal is speculative and reverted,
+// so it passes false; only Observe's real, committed traversal should keep
+// ForgetPast's cold prefixes from looking warm.
+func (model *TrackedCTW) traverse(bit int, mark bool) []snapshot {
+	traversed := []snapshot{}
+	node := model.Root
+	node.mu.Lock()
+	traversed = append(traversed, snapshot{node: node, state: *node, isNew: false})
+	krichevskyTrofimov(node, bit)
+
+	path := make([]int, 0, len(model.Bits))
+	for d := 0; d < len(model.Bits); d++ {
+		b := model.Bits[len(model.Bits)-1-d]
+		path = append(path, b)
+		key := prefixKey(path)
+		if _, ok := model.tracked[key]; !ok {
+			shared := model.shared
+			shared.mu.Lock()
+			traversed = append(traversed, snapshot{node: shared, state: *shared, isNew: false})
+			krichevskyTrofimov(shared, bit)
+			break
+		}
+
+		isNew := false
+		var next *treeNode
+		if b == 0 {
+			if node.Right == nil {
+				node.Right = newTreeNode()
+				isNew = true
+			}
+			next = node.Right
+		} else {
+			if node.Left == nil {
+				node.Left = newTreeNode()
+				isNew = true
+			}
+			next = node.Left
+		}
+		next.mu.Lock()
+		node = next
+
+		traversed = append(traversed, snapshot{node: node, state: *node, isNew: isNew})
+		krichevskyTrofimov(node, bit)
+		if mark {
+			model.lastObs[key] = model.obs
+		}
+	}
+
+	for i := len(traversed) - 1; i >= 0; i-- {
+		n := traversed[i].node
+		var lp, rp float64
+		if n.Left != nil {
+			lp = n.Left.LogProb
+		}
+		if n.Right != nil {
+			rp = n.Right.LogProb
+		}
+		if n.Left != nil || n.Right != nil {
+			w := 0.5
+			n.LogProb = logaddexp(math.Log(w)+n.Lktp, math.Log(1-w)+lp+rp)
+		} else {
+			n.LogProb = n.Lktp
+		}
+	}
+
+	return traversed
+}
+
+// Prob0 returns the probability that the next bit be zero.
+func (model *TrackedCTW) Prob0() float64 {
+	before := model.Root.LogProb
+	traversal := model.traverse(0, false)
+	after := model.Root.LogProb
+	revert(traversal)
+	unlock(traversal)
+	return math.Exp(after - before)
+}
+
+// Observe updates the context tree, given that the sequence is followed by bit.
+func (model *TrackedCTW) Observe(bit int) {
+	unlock(model.traverse(bit, true))
+	model.obs++
+	for i := 1; i < len(model.Bits); i++ {
+		model.Bits[i-1] = model.Bits[i]
+	}
+	model.Bits[len(model.Bits)-1] = bit
+}
+
+// ForgetPast drops the nodes and counts of tracked prefixes that have not
+// been touched by an observation in the last n observations, untracking
+// them so later observations of that context collapse back onto the shared
+// leaf until the caller calls Track again. Only prefixes with no still-warm
+// descendant are dropped, since a node can't be reclaimed while its
+// children are still in use. A prefix that Track declared but no
+// observation has touched yet is not dropped.
+func (model *TrackedCTW) ForgetPast(n int) {
+	cutoff := model.obs - n
+	hasWarmChild := map[string]bool{}
+	for key, last := range model.lastObs {
+		if last >= cutoff && len(key) > 0 {
+			hasWarmChild[key[:len(key)-1]] = true
+		}
+	}
+	for key, last := range model.lastObs {
+		if last >= cutoff || hasWarmChild[key] {
+			continue
+		}
+		model.unlink(key)
+		delete(model.tracked, key)
+		delete(model.lastObs, key)
+	}
+}
+
+// unlink removes the node reached by the traversal-order path key from its
+// parent, freeing it and everything beneath it for garbage collection.
+func (model *TrackedCTW) unlink(key string) {
+	if key == "" {
+		return
+	}
+	node := model.Root
+	for i := 0; i < len(key)-1; i++ {
+		if key[i] == '0' {
+			node = node.Right
+		} else {
+			node = node.Left
+		}
+		if node == nil {
+			return
+		}
+	}
+	if key[len(key)-1] == '0' {
+		node.Right = nil
+	} else {
+		node.Left = nil
+	}
+}
@@ -0,0 +1,65 @@
+package ctw
+
+import "testing"
+
+// TestTrackedCTWProb0DoesNotMarkObservations checks that Prob0, a
+// speculative query, does not count as touching a tracked prefix: only
+// Observe should keep ForgetPast's "used in the last n observations" clock
+// ticking for lastObs.
+func TestTrackedCTWProb0DoesNotMarkObservations(t *testing.T) {
+	// Bits is in chronological order, but traverse reads it most-recent-first,
+	// so [0, 1] puts the tracked prefix in Bits[2:] as the context Track(...)
+	// declares: Bits[3]=0 first, Bits[2]=1 second.
+	model := NewTrackedCTW([]int{0, 0, 1, 0})
+	model.Track([]int{0, 1})
+
+	model.Observe(1)
+	key := prefixKey([]int{0, 1})
+	touchedAt, ok := model.lastObs[key]
+	if !ok {
+		t.Fatalf("expected %q to be tracked after Observe", key)
+	}
+
+	// Restore the context to the tracked path and advance obs without going
+	// through Observe, so a buggy traverse that stamps lastObs from Prob0
+	// would be caught even though obs doesn't move on its own between calls.
+	model.Bits = []int{0, 0, 1, 0}
+	model.obs = touchedAt + 100
+	for i := 0; i < 10; i++ {
+		model.Prob0()
+	}
+	if got := model.lastObs[key]; got != touchedAt {
+		t.Fatalf("Prob0 must not update lastObs: got %d, want %d", got, touchedAt)
+	}
+}
+
+// TestTrackedCTWForgetPastDropsColdPrefixes checks that a prefix untouched
+// for n observations is untracked by ForgetPast and removed from lastObs.
+func TestTrackedCTWForgetPastDropsColdPrefixes(t *testing.T) {
+	// As above, [0, 1] must land in Bits[2:] for the context to match the
+	// tracked prefix on the very next Observe.
+	model := NewTrackedCTW([]int{0, 0, 1, 0})
+	model.Track([]int{0, 1})
+	model.Observe(1)
+
+	key := prefixKey([]int{0, 1})
+	if _, ok := model.tracked[key]; !ok {
+		t.Fatalf("expected %q to be tracked", key)
+	}
+	if _, ok := model.lastObs[key]; !ok {
+		t.Fatalf("expected %q to have been touched by Observe", key)
+	}
+
+	// Drive enough unrelated observations that the tracked prefix goes cold.
+	for i := 0; i < 5; i++ {
+		model.Observe(1)
+	}
+	model.ForgetPast(3)
+
+	if _, ok := model.tracked[key]; ok {
+		t.Fatalf("expected %q to be untracked after it went cold", key)
+	}
+	if _, ok := model.lastObs[key]; ok {
+		t.Fatalf("expected %q to be removed from lastObs after ForgetPast", key)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"flag"
 	// "log"
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"os"
 
@@ -12,6 +11,7 @@ import (
 )
 
 var depth = flag.Int("depth", 56, "depth of Context Tree Weighting")
+var format = flag.String("format", "binary", "trained model file format: binary or json")
 
 func train_model(name string, model *ctw.CTW) {
 
@@ -56,20 +56,27 @@ func train_model(name string, model *ctw.CTW) {
 	fmt.Printf("%e\n", model.Prob0())
 	vom_model := ctw.ToVOM(model)
 
-	// TODO: Dump the model as json
-	f1, err := os.Create("model.json")
+	var outName string
+	if *format == "json" {
+		outName = "model.json"
+	} else {
+		outName = "model.bin"
+	}
+	f1, err := os.Create(outName)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
-	jsonBytes, err := json.Marshal(vom_model)
+	defer f1.Close()
+
+	if *format == "json" {
+		err = vom_model.SaveJSON(f1)
+	} else {
+		err = vom_model.Save(f1)
+	}
 	if err != nil {
 		fmt.Println("Error:", err)
-		return
 	}
-	//fmt.Println(string(jsonBytes))
-	f1.Write(jsonBytes)
-	f1.Close()
 }
 
 func main() {